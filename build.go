@@ -2,42 +2,105 @@ package main
 
 import (
 	"bytes"
+	"container/heap"
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
-	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+
+	"github.com/brerodrigues/tor-static/components"
 )
 
 var verbose bool
-var folders = []string{"openssl", "libevent", "zlib", "xz", "tor"}
 var absCurrDir = getAbsCurrDir()
+var buildP int
+var outputDir string
+var builder string
+var configPath string
+
+// registry holds the components this run builds/cleans/packages: the
+// built-in openssl/libevent/zlib/xz/tor pipeline, plus whatever
+// tor-static.toml added or overrode.
+var registry *components.Registry
 
 func main() {
 	flag.BoolVar(&verbose, "verbose", false, "Whether to show command output")
+	targetFlag := flag.String("target", "", "GOOS/GOARCH to cross-compile for, e.g. linux/arm64 (default: host)")
+	targetsAll := flag.Bool("targets-all", false, "Build for every target in the supported cross-compilation matrix")
+	flag.IntVar(&buildP, "p", runtime.NumCPU(),
+		"Number of independent components to build concurrently for build-all, mirroring go build -p")
+	flag.StringVar(&outputDir, "output-dir", "dist", "Directory package-all/package-<folder> write archives to")
+	flag.StringVar(&builder, "builder", "local", "Build backend to run component builds in: local, docker, or podman")
+	flag.StringVar(&configPath, "config", "tor-static.toml",
+		"Optional TOML file adding or overriding components")
 	flag.Parse()
 	if len(flag.Args()) != 1 {
-		log.Fatal("Missing command. Can be build-all, build-<folder>, clean-all, or clean-<folder>")
+		log.Fatal("Missing command. Can be build-all, build-<folder>, clean-all, clean-<folder>, " +
+			"package-all, or package-<folder>")
+	}
+
+	extra, err := components.LoadConfigFile(configPath)
+	if err != nil {
+		log.Fatalf("Loading %v: %v", configPath, err)
+	}
+	registry = components.NewRegistry(extra...)
+	if err := registry.Validate(); err != nil {
+		log.Fatalf("Invalid component registry: %v", err)
 	}
-	if err := run(flag.Args()[0]); err != nil {
+
+	targetsToRun, err := resolveTargets(*targetFlag, *targetsAll)
+	if err != nil {
 		log.Fatal(err)
 	}
+	for _, t := range targetsToRun {
+		if err := run(flag.Args()[0], t); err != nil {
+			log.Fatal(err)
+		}
+	}
 }
 
-func run(cmd string) error {
-	if err := validateEnvironment(); err != nil {
+// resolveTargets turns the -target/-targets-all flags into the list of
+// targets to build. With neither flag set, it returns just the host target
+// so existing invocations behave exactly as before.
+func resolveTargets(targetFlag string, targetsAll bool) ([]components.Target, error) {
+	if targetsAll && targetFlag != "" {
+		return nil, fmt.Errorf("-target and -targets-all are mutually exclusive")
+	}
+	if targetsAll {
+		return components.Targets, nil
+	}
+	if targetFlag != "" {
+		t, err := components.LookupTarget(targetFlag)
+		if err != nil {
+			return nil, err
+		}
+		return []components.Target{t}, nil
+	}
+	return []components.Target{components.HostTarget()}, nil
+}
+
+func run(cmd string, target components.Target) error {
+	if strings.HasPrefix(cmd, "package-") {
+		// Packaging only reads already-built dist/ trees, so it doesn't need
+		// the toolchain/MinGW checks that building and cleaning do.
+		return packageCmd(cmd[8:], target)
+	}
+	if err := validateEnvironment(target); err != nil {
 		return err
 	}
 	if strings.HasPrefix(cmd, "build-") {
-		return build(cmd[6:])
+		return build(cmd[6:], target)
 	} else if strings.HasPrefix(cmd, "clean-") {
-		return clean(cmd[6:])
+		return clean(cmd[6:], target)
 	}
-	return fmt.Errorf("Invalid command: %v. Should be build-all, build-<folder>, clean-all, or clean-<folder>", cmd)
+	return fmt.Errorf("Invalid command: %v. Should be build-all, build-<folder>, clean-all, clean-<folder>, "+
+		"package-all, or package-<folder>", cmd)
 }
 
 func getAbsCurrDir() string {
@@ -53,13 +116,20 @@ func getAbsCurrDir() string {
 	return absCurrDir
 }
 
-func validateEnvironment() error {
-	// Make sure all the folders are there
-	for _, folder := range folders {
-		if info, err := os.Stat(folder); err != nil || !info.IsDir() {
-			return fmt.Errorf("%v is not a dir", folder)
+func validateEnvironment(target components.Target) error {
+	// Make sure all the component folders are there
+	for _, name := range registry.Names() {
+		if info, err := os.Stat(name); err != nil || !info.IsDir() {
+			return fmt.Errorf("%v is not a dir", name)
 		}
 	}
+	// Cross-compiling relies on an external toolchain rather than the host's
+	// native build tools, so the MinGW/MSYS host checks below only apply to
+	// native builds. A container builder brings its own pinned toolchain
+	// too, regardless of target, so it skips these checks as well.
+	if !target.Native() || builder != "local" {
+		return nil
+	}
 	switch runtime.GOOS {
 	// On windows, have to verify MinGW
 	case "windows":
@@ -107,140 +177,175 @@ func validateEnvironment() error {
 	return nil
 }
 
-func build(folder string) error {
-	log.Printf("*** Building %v ***", folder)
-	defer log.Printf("*** Done building %v ***", folder)
-	pwd := absCurrDir + "/" + folder
-	switch folder {
-	case "all":
-		for _, subFolder := range folders {
-			if err := clean(subFolder); err != nil {
-				return err
-			}
+func newEnv(target components.Target) *components.Env {
+	return &components.Env{
+		Target:  target,
+		RepoDir: absCurrDir,
+		Builder: builder,
+		Verbose: verbose,
+	}
+}
+
+func build(name string, target components.Target) error {
+	if name == "all" {
+		return buildAll(target)
+	}
+	comp, ok := registry.Get(name)
+	if !ok {
+		return fmt.Errorf("Unrecognized folder: %v", name)
+	}
+	return buildComponent(context.Background(), comp, target)
+}
+
+// buildComponent runs a single component's Configure/Build/Install in order,
+// stopping early if ctx is cancelled (e.g. a sibling component failed during
+// build-all).
+func buildComponent(ctx context.Context, comp components.Component, target components.Target) error {
+	name := comp.Name()
+	components.LogFolder(name, "*** Building for %v ***", target)
+	defer components.LogFolder(name, "*** Done building for %v ***", target)
+	env := newEnv(target)
+	for _, phase := range []struct {
+		name string
+		run  func(context.Context, *components.Env) error
+	}{
+		{"Configure", comp.Configure},
+		{"Compile", comp.Build},
+		{"Install", comp.Install},
+	} {
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
-		return nil
-	case "openssl":
-		cmds := [][]string{
-			{"sh", "./config", "--prefix=" + pwd + "/dist", "no-shared", "no-dso", "no-zlib"},
-			{"make", "depend"},
-			{"make"},
-			{"make", "install"},
-		}
-		if runtime.GOOS == "windows" {
-			cmds[0] = append(cmds[0], "mingw64")
-			cmds[0][1] = "./Configure"
-		}
-		return runCmds(folder, nil, cmds)
-	case "libevent":
-		return runCmds(folder, nil, [][]string{
-			{"sh", "-l", "./autogen.sh"},
-			{"sh", "./configure", "--prefix=" + pwd + "/dist",
-				"--disable-shared", "--enable-static", "--with-pic"},
-			{"make"},
-			{"make", "install"},
-		})
-	case "zlib":
-		var env []string
-		cmds := [][]string{{"sh", "./configure", "--prefix=" + pwd + "/dist"}, {"make"}, {"make", "install"}}
-		if runtime.GOOS == "windows" {
-			env = []string{"PREFIX=" + pwd + "/dist", "BINARY_PATH=" + pwd + "/dist/bin",
-				"INCLUDE_PATH=" + pwd + "/dist/include", "LIBRARY_PATH=" + pwd + "/dist/lib"}
-			cmds = [][]string{{"make", "-fwin32/Makefile.gcc"}, {"make", "install", "-fwin32/Makefile.gcc"}}
-		}
-		return runCmds(folder, env, cmds)
-	case "xz":
-		return runCmds(folder, nil, [][]string{
-			{"sh", "-l", "./autogen.sh"},
-			{"sh", "./configure", "--prefix=" + pwd + "/dist", "--disable-shared", "--enable-static",
-				"--disable-doc", "--disable-scripts", "--disable-xz", "--disable-xzdec", "--disable-lzmadec",
-				"--disable-lzmainfo", "--disable-lzma-links"},
-			{"make"},
-			{"make", "install"},
-		})
-	case "tor":
-		// We have to make a symlink from zlib to openssl
-		if _, err := os.Stat("openssl/dist/lib/libz.a"); os.IsNotExist(err) {
-			err = runCmd("", nil, "ln", "-s", pwd+"/../zlib/dist/lib/libz.a", pwd+"/../openssl/dist/lib/libz.a")
-			if err != nil {
-				return fmt.Errorf("Unable to make symlink: %v", err)
-			}
+		components.LogFolder(name, "-- %v --", phase.name)
+		if err := phase.run(ctx, env); err != nil {
+			return fmt.Errorf("%v phase: %w", phase.name, err)
 		}
-		var env []string
-		if runtime.GOOS == "windows" {
-			env = []string{"LIBS=-lcrypt32"}
-		}
-		return runCmds(folder, env, [][]string{
-			{"sh", "-l", "./autogen.sh"},
-			{"sh", "./configure", "--prefix=" + pwd + "/dist", "--disable-gcc-hardening", "--enable-static-tor",
-				"--enable-static-libevent", "--with-libevent-dir=" + pwd + "/../libevent/dist", "--enable-static-openssl",
-				"--with-openssl-dir=" + pwd + "/../openssl/dist", "--enable-static-zlib",
-				"--with-zlib-dir=" + pwd + "/../openssl/dist", "--disable-system-torrc", "--disable-asciidoc"},
-			{"make"},
-			{"make", "install"},
-		})
-	default:
-		return fmt.Errorf("Unrecognized folder: %v", folder)
 	}
+	return nil
 }
 
-func clean(folder string) (err error) {
-	log.Printf("*** Cleaning %v ***", folder)
-	defer log.Printf("*** Done cleaning %v ***", folder)
-	switch folder {
-	case "all":
-		for _, subFolder := range folders {
-			if err = clean(subFolder); err != nil {
-				break
-			}
-		}
-	default:
-		args := []string{"clean"}
-		env := []string{}
-		makefile := "Makefile"
-		switch folder {
-		// OpenSSL needs to have the dist folder removed first
-		case "openssl":
-			if err := os.RemoveAll("openssl/dist/lib"); err != nil {
-				return fmt.Errorf("Unable to remove openssl/dist/lib: %v", err)
-			}
-		// Zlib needs to have a prefix and needs a special windows makefile
-		case "zlib":
-			env = append(env, "PREFIX="+absCurrDir+"/zlib/dist")
-			if runtime.GOOS == "windows" {
-				makefile = "win32/Makefile.gcc"
-				args = append(args, "-fwin32/Makefile.gcc")
-			}
+// buildAll builds every registered component for target, running components
+// with no unmet dependencies concurrently. Up to buildP components build at
+// once; the first failure cancels every component still pending or in
+// flight.
+func buildAll(target components.Target) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := buildP
+	if p < 1 {
+		p = 1
+	}
+
+	all := registry.All()
+	remaining := map[string]int{}
+	dependents := map[string][]string{}
+	for _, c := range all {
+		remaining[c.Name()] = len(c.Dependencies())
+		for _, dep := range c.Dependencies() {
+			dependents[dep] = append(dependents[dep], c.Name())
 		}
-		if dir, err := os.Stat(folder); err != nil || !dir.IsDir() {
-			return fmt.Errorf("%v is not a directory", folder)
-		} else if _, err := os.Stat(path.Join(folder, makefile)); os.IsNotExist(err) {
-			log.Printf("Skipping clean, makefile not present")
-			return nil
+	}
+
+	var mu sync.Mutex
+	cond := sync.NewCond(&mu)
+	ready := &readyQueue{}
+	heap.Init(ready)
+	for _, c := range all {
+		if remaining[c.Name()] == 0 {
+			heap.Push(ready, &readyItem{name: c.Name(), priority: len(dependents[c.Name()])})
 		}
-		err = runCmd(folder, env, "make", args...)
 	}
-	return err
-}
 
-func runCmds(folder string, env []string, cmdsAndArgs [][]string) error {
-	for _, cmdAndArgs := range cmdsAndArgs {
-		if err := runCmd(folder, env, cmdAndArgs[0], cmdAndArgs[1:]...); err != nil {
-			return err
+	sem := make(chan struct{}, p)
+	var wg sync.WaitGroup
+	var firstErr error
+	failed := false
+	done := 0
+
+	for {
+		mu.Lock()
+		for ready.Len() == 0 && !failed && done < len(all) {
+			cond.Wait()
+		}
+		if failed || done >= len(all) {
+			mu.Unlock()
+			break
 		}
+		item := heap.Pop(ready).(*readyItem)
+		mu.Unlock()
+
+		comp, _ := registry.Get(item.name)
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(comp components.Component) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			buildErr := buildComponent(ctx, comp, target)
+
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			if buildErr != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%v: %w", comp.Name(), buildErr)
+				}
+				failed = true
+				cancel()
+				cond.Broadcast()
+				return
+			}
+			for _, dep := range dependents[comp.Name()] {
+				remaining[dep]--
+				if remaining[dep] == 0 {
+					heap.Push(ready, &readyItem{name: dep, priority: len(dependents[dep])})
+				}
+			}
+			cond.Broadcast()
+		}(comp)
 	}
-	return nil
+	wg.Wait()
+	return firstErr
+}
+
+// readyItem is a component whose dependencies are all satisfied, waiting to
+// be scheduled. priority is the number of downstream components blocked on
+// it, so components that unblock the most work are built first.
+type readyItem struct {
+	name     string
+	priority int
 }
 
-func runCmd(folder string, env []string, cmd string, args ...string) error {
-	log.Printf("Running in folder %v: %v %v", folder, cmd, strings.Join(args, " "))
-	c := exec.Command(cmd, args...)
-	if len(env) > 0 {
-		c.Env = append(os.Environ(), env...)
+// readyQueue is a container/heap priority queue of readyItems, highest
+// priority first.
+type readyQueue []*readyItem
+
+func (q readyQueue) Len() int            { return len(q) }
+func (q readyQueue) Less(i, j int) bool  { return q[i].priority > q[j].priority }
+func (q readyQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *readyQueue) Push(x interface{}) { *q = append(*q, x.(*readyItem)) }
+func (q *readyQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	*q = old[:n-1]
+	return item
+}
+
+func clean(name string, target components.Target) (err error) {
+	if name == "all" {
+		for _, c := range registry.All() {
+			if err = clean(c.Name(), target); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
-	c.Dir = folder
-	if verbose {
-		c.Stdout = os.Stdout
-		c.Stderr = os.Stderr
+	comp, ok := registry.Get(name)
+	if !ok {
+		return fmt.Errorf("Unrecognized folder: %v", name)
 	}
-	return c.Run()
-}
\ No newline at end of file
+	log.Printf("*** Cleaning %v ***", name)
+	defer log.Printf("*** Done cleaning %v ***", name)
+	return comp.Clean(context.Background(), newEnv(target))
+}
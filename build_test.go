@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brerodrigues/tor-static/components"
+)
+
+// fakeComponent is a Component whose phases record call order instead of
+// shelling out, so buildAll's scheduling logic can be tested without a real
+// toolchain or filesystem.
+type fakeComponent struct {
+	name string
+	deps []string
+	fail bool
+
+	mu      *sync.Mutex
+	started *[]string
+	delay   time.Duration
+}
+
+func (c *fakeComponent) Name() string           { return c.name }
+func (c *fakeComponent) Dependencies() []string { return c.deps }
+
+func (c *fakeComponent) Configure(ctx context.Context, env *components.Env) error {
+	c.mu.Lock()
+	*c.started = append(*c.started, c.name)
+	c.mu.Unlock()
+	if c.delay > 0 {
+		time.Sleep(c.delay)
+	}
+	return nil
+}
+
+func (c *fakeComponent) Build(ctx context.Context, env *components.Env) error {
+	if c.fail {
+		return fmt.Errorf("%v: simulated build failure", c.name)
+	}
+	return nil
+}
+
+func (c *fakeComponent) Install(ctx context.Context, env *components.Env) error { return nil }
+func (c *fakeComponent) Clean(ctx context.Context, env *components.Env) error   { return nil }
+
+// indexOf returns the position of name in order, or -1 if absent.
+func indexOf(order []string, name string) int {
+	for i, n := range order {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestBuildAllRespectsDependencies(t *testing.T) {
+	var mu sync.Mutex
+	var started []string
+
+	origRegistry := registry
+	defer func() { registry = origRegistry }()
+
+	// Name every fake after a built-in so NewRegistry's override-by-name
+	// replaces all five defaults: their real Configure/Build would shell
+	// out and fail outside a real checkout.
+	registry = components.NewRegistry(
+		&fakeComponent{name: "zlib", mu: &mu, started: &started},
+		&fakeComponent{name: "openssl", mu: &mu, started: &started},
+		&fakeComponent{name: "libevent", mu: &mu, started: &started},
+		&fakeComponent{name: "xz", mu: &mu, started: &started},
+		&fakeComponent{name: "tor", deps: []string{"zlib", "openssl", "libevent", "xz"}, mu: &mu, started: &started},
+	)
+
+	if err := buildAll(components.Target{GOOS: "linux", GOARCH: "amd64"}); err != nil {
+		t.Fatalf("buildAll: %v", err)
+	}
+
+	torIdx := indexOf(started, "tor")
+	if torIdx == -1 {
+		t.Fatalf("tor never started, order: %v", started)
+	}
+	for _, dep := range []string{"zlib", "openssl"} {
+		if depIdx := indexOf(started, dep); depIdx == -1 || depIdx > torIdx {
+			t.Errorf("expected %v to start before tor, order: %v", dep, started)
+		}
+	}
+}
+
+func TestBuildAllCancelsOnFailure(t *testing.T) {
+	var mu sync.Mutex
+	var started []string
+
+	origRegistry := registry
+	defer func() { registry = origRegistry }()
+
+	registry = components.NewRegistry(
+		&fakeComponent{name: "zlib", fail: true, mu: &mu, started: &started},
+		&fakeComponent{name: "openssl", mu: &mu, started: &started},
+		&fakeComponent{name: "libevent", mu: &mu, started: &started},
+		&fakeComponent{name: "xz", mu: &mu, started: &started},
+		&fakeComponent{name: "tor", deps: []string{"zlib", "openssl", "libevent", "xz"}, mu: &mu, started: &started, delay: 50 * time.Millisecond},
+	)
+
+	err := buildAll(components.Target{GOOS: "linux", GOARCH: "amd64"})
+	if err == nil {
+		t.Fatal("expected buildAll to report zlib's failure, got nil")
+	}
+
+	if indexOf(started, "tor") != -1 {
+		t.Errorf("tor should never start once its dependency zlib fails, order: %v", started)
+	}
+}
@@ -0,0 +1,30 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path"
+)
+
+// cleanMakefile runs "make clean" (plus any extraMakeArgs, e.g. a
+// non-default -f<makefile>) in folder, skipping quietly if the folder has
+// never been configured. makefile is the path (relative to folder) whose
+// presence gates whether there's anything to clean. Shared by every
+// built-in component's Clean, since they all boil down to this modulo
+// folder-specific setup.
+func cleanMakefile(ctx context.Context, env *Env, folder, makefile string, cmdEnv []string, extraMakeArgs []string) error {
+	if dir, err := os.Stat(folder); err != nil || !dir.IsDir() {
+		return fmt.Errorf("%v is not a directory", folder)
+	}
+	if makefile == "" {
+		makefile = "Makefile"
+	}
+	if _, err := os.Stat(path.Join(folder, makefile)); os.IsNotExist(err) {
+		log.Printf("Skipping clean, makefile not present")
+		return nil
+	}
+	args := append([]string{"clean"}, extraMakeArgs...)
+	return RunCmd(ctx, env, folder, cmdEnv, "make", args...)
+}
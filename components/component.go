@@ -0,0 +1,136 @@
+// Package components defines the pluggable build-component abstraction that
+// replaced tor-static's original hard-coded openssl/libevent/zlib/xz/tor
+// pipeline and its giant switch-on-folder-name build/clean functions. Each
+// Component knows how to configure, build, install, and clean itself for a
+// given Target; main drives the set of Components as a dependency graph
+// instead.
+package components
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Component is a single piece of the static-libs tree: one of the built-in
+// openssl/libevent/zlib/xz/tor folders, or one added or overridden via
+// tor-static.toml.
+type Component interface {
+	// Name is the folder this component lives and builds in, relative to
+	// the repo root.
+	Name() string
+	// Dependencies lists the Name()s of components that must finish
+	// Install before this one's Configure can start.
+	Dependencies() []string
+	Configure(ctx context.Context, env *Env) error
+	Build(ctx context.Context, env *Env) error
+	Install(ctx context.Context, env *Env) error
+	Clean(ctx context.Context, env *Env) error
+}
+
+// Registry holds the set of components a build run operates over: the
+// built-ins, plus whatever tor-static.toml added or overrode.
+type Registry struct {
+	order  []string
+	byName map[string]Component
+}
+
+// NewRegistry builds the default openssl/libevent/zlib/xz/tor registry, then
+// applies extra on top of it. A Component in extra whose Name matches a
+// built-in replaces it; otherwise it's appended.
+func NewRegistry(extra ...Component) *Registry {
+	r := &Registry{byName: map[string]Component{}}
+	for _, c := range defaultComponents() {
+		r.add(c)
+	}
+	for _, c := range extra {
+		r.add(c)
+	}
+	return r
+}
+
+func (r *Registry) add(c Component) {
+	if _, exists := r.byName[c.Name()]; !exists {
+		r.order = append(r.order, c.Name())
+	}
+	r.byName[c.Name()] = c
+}
+
+// Names returns every registered component's name, in registration order
+// (built-ins first, in their historical openssl/libevent/zlib/xz/tor order,
+// then tor-static.toml additions in file order).
+func (r *Registry) Names() []string {
+	names := make([]string, len(r.order))
+	copy(names, r.order)
+	return names
+}
+
+// Get looks up a component by name.
+func (r *Registry) Get(name string) (Component, bool) {
+	c, ok := r.byName[name]
+	return c, ok
+}
+
+// All returns every registered component, in registration order.
+func (r *Registry) All() []Component {
+	all := make([]Component, len(r.order))
+	for i, name := range r.order {
+		all[i] = r.byName[name]
+	}
+	return all
+}
+
+// Validate checks that every component's Dependencies() name another
+// registered component and that the dependency graph has no cycles.
+// Without this check, an unknown or circular dependency coming from
+// tor-static.toml would leave the build-all scheduler waiting forever on a
+// component that can never become ready.
+func (r *Registry) Validate() error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(r.order))
+
+	var visit func(name string, chain []string) error
+	visit = func(name string, chain []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: %v -> %v", strings.Join(chain, " -> "), name)
+		}
+		state[name] = visiting
+		c := r.byName[name]
+		for _, dep := range c.Dependencies() {
+			if _, ok := r.byName[dep]; !ok {
+				return fmt.Errorf("%v depends on unknown component %q", name, dep)
+			}
+			if err := visit(dep, append(chain, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+
+	for _, name := range r.order {
+		if state[name] == unvisited {
+			if err := visit(name, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func defaultComponents() []Component {
+	return []Component{
+		&opensslComponent{},
+		&libeventComponent{},
+		&zlibComponent{},
+		&xzComponent{},
+		&torComponent{},
+	}
+}
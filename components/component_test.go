@@ -0,0 +1,48 @@
+package components
+
+import (
+	"context"
+	"testing"
+)
+
+// stubComponent is a minimal Component for exercising Registry.Validate,
+// which only ever looks at Name/Dependencies.
+type stubComponent struct {
+	name string
+	deps []string
+}
+
+func (c *stubComponent) Name() string                          { return c.name }
+func (c *stubComponent) Dependencies() []string                { return c.deps }
+func (c *stubComponent) Configure(context.Context, *Env) error { return nil }
+func (c *stubComponent) Build(context.Context, *Env) error     { return nil }
+func (c *stubComponent) Install(context.Context, *Env) error   { return nil }
+func (c *stubComponent) Clean(context.Context, *Env) error     { return nil }
+
+func TestRegistryValidateOK(t *testing.T) {
+	r := NewRegistry(
+		&stubComponent{name: "zlib"},
+		&stubComponent{name: "openssl"},
+		&stubComponent{name: "tor", deps: []string{"zlib", "openssl"}},
+	)
+	if err := r.Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+}
+
+func TestRegistryValidateUnknownDependency(t *testing.T) {
+	r := NewRegistry(&stubComponent{name: "foo", deps: []string{"bar"}})
+	if err := r.Validate(); err == nil {
+		t.Error("Validate() = nil, want an error for a dependency on an unregistered component")
+	}
+}
+
+func TestRegistryValidateCycle(t *testing.T) {
+	r := NewRegistry(
+		&stubComponent{name: "a", deps: []string{"b"}},
+		&stubComponent{name: "b", deps: []string{"a"}},
+	)
+	if err := r.Validate(); err == nil {
+		t.Error("Validate() = nil, want an error for a dependency cycle")
+	}
+}
@@ -0,0 +1,164 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// shellComponent is a Component driven entirely by shell commands read from
+// tor-static.toml, letting users add components (obfs4proxy, snowflake, an
+// extra static blob) or override a built-in one (swap OpenSSL for LibreSSL,
+// pin different configure flags) without touching Go code.
+type shellComponent struct {
+	name         string
+	dependencies []string
+	configure    string
+	build        string
+	install      string
+	clean        string
+}
+
+func (c *shellComponent) Name() string           { return c.name }
+func (c *shellComponent) Dependencies() []string { return c.dependencies }
+
+func (c *shellComponent) Configure(ctx context.Context, env *Env) error {
+	return c.run(ctx, env, c.configure)
+}
+func (c *shellComponent) Build(ctx context.Context, env *Env) error { return c.run(ctx, env, c.build) }
+func (c *shellComponent) Install(ctx context.Context, env *Env) error {
+	return c.run(ctx, env, c.install)
+}
+func (c *shellComponent) Clean(ctx context.Context, env *Env) error { return c.run(ctx, env, c.clean) }
+
+func (c *shellComponent) run(ctx context.Context, env *Env, cmd string) error {
+	if cmd == "" {
+		return nil
+	}
+	cmd = strings.NewReplacer(
+		"{{dist}}", env.Dist(c.name),
+		"{{repo}}", env.RepoDir,
+	).Replace(cmd)
+	return RunCmd(ctx, env, c.name, env.Target.CrossEnv(), "sh", "-c", cmd)
+}
+
+// LoadConfigFile reads tor-static.toml from path, returning the Components
+// it defines. A missing file is not an error: it returns (nil, nil) so
+// callers can treat tor-static.toml as fully optional.
+func LoadConfigFile(path string) ([]Component, error) {
+	byts, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(string(byts))
+}
+
+// parseConfig is a deliberately minimal TOML reader: tor-static.toml only
+// ever needs one shape, a list of [[component]] tables with a handful of
+// string/string-array fields, so a hand-rolled parser for exactly that
+// shape is simpler and more honest than vendoring a general TOML library
+// tor-static otherwise has no need for.
+func parseConfig(src string) ([]Component, error) {
+	var comps []Component
+	var cur *shellComponent
+
+	flush := func() {
+		if cur != nil {
+			comps = append(comps, cur)
+		}
+	}
+
+	for i, rawLine := range strings.Split(src, "\n") {
+		line := strings.TrimSpace(rawLine)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "[[component]]" {
+			flush()
+			cur = &shellComponent{}
+			continue
+		}
+		if cur == nil {
+			return nil, fmt.Errorf("tor-static.toml:%d: content before the first [[component]]", i+1)
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("tor-static.toml:%d: expected key = value", i+1)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		switch key {
+		case "name":
+			s, err := parseTOMLString(value)
+			if err != nil {
+				return nil, fmt.Errorf("tor-static.toml:%d: %v", i+1, err)
+			}
+			cur.name = s
+		case "dependencies":
+			deps, err := parseTOMLStringArray(value)
+			if err != nil {
+				return nil, fmt.Errorf("tor-static.toml:%d: %v", i+1, err)
+			}
+			cur.dependencies = deps
+		case "configure", "build", "install", "clean":
+			s, err := parseTOMLString(value)
+			if err != nil {
+				return nil, fmt.Errorf("tor-static.toml:%d: %v", i+1, err)
+			}
+			switch key {
+			case "configure":
+				cur.configure = s
+			case "build":
+				cur.build = s
+			case "install":
+				cur.install = s
+			case "clean":
+				cur.clean = s
+			}
+		default:
+			return nil, fmt.Errorf("tor-static.toml:%d: unknown key %q", i+1, key)
+		}
+	}
+	flush()
+
+	for _, c := range comps {
+		if c.(*shellComponent).name == "" {
+			return nil, fmt.Errorf("tor-static.toml: [[component]] missing required \"name\"")
+		}
+	}
+	return comps, nil
+}
+
+var tomlStringRe = regexp.MustCompile(`^"((?:[^"\\]|\\.)*)"$`)
+
+func parseTOMLString(value string) (string, error) {
+	m := tomlStringRe.FindStringSubmatch(value)
+	if m == nil {
+		return "", fmt.Errorf("expected a quoted string, got %q", value)
+	}
+	return strings.ReplaceAll(m[1], `\"`, `"`), nil
+}
+
+func parseTOMLStringArray(value string) ([]string, error) {
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return nil, fmt.Errorf("expected a string array, got %q", value)
+	}
+	inner := strings.TrimSpace(value[1 : len(value)-1])
+	if inner == "" {
+		return nil, nil
+	}
+	var out []string
+	for _, part := range strings.Split(inner, ",") {
+		s, err := parseTOMLString(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
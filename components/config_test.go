@@ -0,0 +1,71 @@
+package components
+
+import "testing"
+
+func TestParseConfig(t *testing.T) {
+	src := `
+[[component]]
+name = "obfs4proxy"
+dependencies = ["openssl", "libevent"]
+configure = "echo configuring"
+build = "make"
+install = "make install"
+clean = "rm -rf {{dist}}"
+
+[[component]]
+name = "snowflake"
+dependencies = []
+`
+	comps, err := parseConfig(src)
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	if len(comps) != 2 {
+		t.Fatalf("expected 2 components, got %v", len(comps))
+	}
+
+	obfs4 := comps[0].(*shellComponent)
+	if obfs4.name != "obfs4proxy" {
+		t.Errorf("name = %q, want obfs4proxy", obfs4.name)
+	}
+	if got := obfs4.Dependencies(); len(got) != 2 || got[0] != "openssl" || got[1] != "libevent" {
+		t.Errorf("dependencies = %v, want [openssl libevent]", got)
+	}
+	if obfs4.configure != "echo configuring" {
+		t.Errorf("configure = %q", obfs4.configure)
+	}
+
+	snowflake := comps[1].(*shellComponent)
+	if snowflake.name != "snowflake" || snowflake.Dependencies() != nil {
+		t.Errorf("snowflake = %+v, want empty dependencies", snowflake)
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+	}{
+		{"content before first table", `name = "x"`},
+		{"missing name", `[[component]]
+configure = "echo hi"`},
+		{"unknown key", `[[component]]
+name = "x"
+bogus = "y"`},
+		{"bad key=value line", `[[component]]
+name`},
+		{"unterminated string", `[[component]]
+name = "x
+configure = "echo hi"`},
+		{"malformed array", `[[component]]
+name = "x"
+dependencies = "not-an-array"`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseConfig(tc.src); err == nil {
+				t.Errorf("parseConfig(%q): expected an error, got nil", tc.src)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Env carries the per-run state a Component needs to build itself: which
+// target it's building for, where the repo lives, which backend to run
+// commands through, and whether to stream subprocess output.
+type Env struct {
+	Target  Target
+	RepoDir string
+	Builder string // "local", "docker", or "podman"
+	Verbose bool
+}
+
+// Dist is the folder this component's build output should be installed to.
+func (e *Env) Dist(name string) string {
+	return e.Target.DistDir(e.RepoDir, name)
+}
+
+// containerBaseImages maps a target's GOOS to the pinned container image its
+// build should run in when Builder is docker or podman.
+var containerBaseImages = map[string]string{
+	"linux":   "debian:bookworm",
+	"windows": "dockcross/windows-static-x64",
+	"darwin":  "crazymax/osxcross:latest",
+}
+
+// logMu serializes log output across components building concurrently so
+// lines from parallel components don't interleave mid-message.
+var logMu sync.Mutex
+
+// LogFolder writes a log line prefixed with the component folder it
+// concerns, which matters once several components are building at once.
+func LogFolder(folder, format string, args ...interface{}) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	log.Printf("[%v] "+format, append([]interface{}{folder}, args...)...)
+}
+
+// RunCmds runs each command in order, stopping at the first failure or if
+// ctx is cancelled.
+func RunCmds(ctx context.Context, env *Env, folder string, cmdEnv []string, cmdsAndArgs [][]string) error {
+	for _, cmdAndArgs := range cmdsAndArgs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if len(cmdAndArgs) == 0 {
+			continue
+		}
+		if err := RunCmd(ctx, env, folder, cmdEnv, cmdAndArgs[0], cmdAndArgs[1:]...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunCmd runs a single command with folder as its working directory,
+// locally or inside a container per env.Builder.
+func RunCmd(ctx context.Context, env *Env, folder string, cmdEnv []string, cmd string, args ...string) error {
+	LogFolder(folder, "Running: %v %v", cmd, strings.Join(args, " "))
+	if env.Builder != "local" {
+		return runCmdContainer(ctx, env, folder, cmdEnv, cmd, args...)
+	}
+	c := exec.CommandContext(ctx, cmd, args...)
+	if len(cmdEnv) > 0 {
+		c.Env = append(os.Environ(), cmdEnv...)
+	}
+	c.Dir = folder
+	if env.Verbose {
+		c.Stdout = os.Stdout
+		c.Stderr = os.Stderr
+	}
+	return c.Run()
+}
+
+// runCmdContainer runs cmd inside an ephemeral docker/podman container built
+// from the pinned base image for env.Target.GOOS, with the repo mounted
+// read-write at /src so the component's build outputs land back on the host
+// exactly as a local build would. This replaces the hard requirement that
+// the host itself carry the right cross-toolchain (e.g. a MinGW64 MSYS
+// shell) with a single, reproducible image per platform.
+//
+// Both cmd's args and cmdEnv values are translated through toContainerPath,
+// since either can carry a host path (e.g. zlib's PREFIX=<repoDir>/...).
+// One known gap: an absolute symlink created inside the container (tor's
+// ln -s for libz.a) points at /src/..., which is only valid inside a
+// container with this same bind mount, so it reads as dangling from the
+// host shell.
+func runCmdContainer(ctx context.Context, env *Env, folder string, cmdEnv []string, cmd string, args ...string) error {
+	image, ok := containerBaseImages[env.Target.GOOS]
+	if !ok {
+		return fmt.Errorf("no container image configured for GOOS %v", env.Target.GOOS)
+	}
+	runArgs := []string{"run", "--rm", "-v", env.RepoDir + ":/src", "-w", "/src/" + folder}
+	for _, e := range cmdEnv {
+		runArgs = append(runArgs, "-e", toContainerPath(env.RepoDir, e))
+	}
+	runArgs = append(runArgs, image, cmd)
+	for _, arg := range args {
+		runArgs = append(runArgs, toContainerPath(env.RepoDir, arg))
+	}
+	c := exec.CommandContext(ctx, env.Builder, runArgs...)
+	if env.Verbose {
+		c.Stdout = os.Stdout
+		c.Stderr = os.Stderr
+	}
+	return c.Run()
+}
+
+// toContainerPath rewrites any host repoDir prefix in a command argument
+// (e.g. a --prefix=<repoDir>/openssl/dist flag) to the /src mount point
+// inside the container.
+func toContainerPath(repoDir, arg string) string {
+	return strings.ReplaceAll(arg, repoDir, "/src")
+}
@@ -0,0 +1,31 @@
+package components
+
+import "context"
+
+// libeventComponent builds libevent as a static, position-independent
+// library.
+type libeventComponent struct{}
+
+func (c *libeventComponent) Name() string           { return "libevent" }
+func (c *libeventComponent) Dependencies() []string { return nil }
+
+func (c *libeventComponent) Configure(ctx context.Context, env *Env) error {
+	if err := RunCmd(ctx, env, c.Name(), nil, "sh", "-l", "./autogen.sh"); err != nil {
+		return err
+	}
+	args := []string{"./configure", "--prefix=" + env.Dist(c.Name()), "--disable-shared", "--enable-static", "--with-pic"}
+	args = append(args, env.Target.CrossConfigureArgs()...)
+	return RunCmd(ctx, env, c.Name(), env.Target.CrossEnv(), "sh", args...)
+}
+
+func (c *libeventComponent) Build(ctx context.Context, env *Env) error {
+	return RunCmd(ctx, env, c.Name(), env.Target.CrossEnv(), "make")
+}
+
+func (c *libeventComponent) Install(ctx context.Context, env *Env) error {
+	return RunCmd(ctx, env, c.Name(), env.Target.CrossEnv(), "make", "install")
+}
+
+func (c *libeventComponent) Clean(ctx context.Context, env *Env) error {
+	return cleanMakefile(ctx, env, c.Name(), "", nil, nil)
+}
@@ -0,0 +1,50 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// opensslComponent builds OpenSSL as a static, no-shared library.
+type opensslComponent struct{}
+
+func (c *opensslComponent) Name() string           { return "openssl" }
+func (c *opensslComponent) Dependencies() []string { return nil }
+
+func (c *opensslComponent) Configure(ctx context.Context, env *Env) error {
+	dist := env.Dist(c.Name())
+	var configure []string
+	if env.Target.Native() && runtime.GOOS != "windows" {
+		// Historically native non-Windows builds used ./config to
+		// auto-detect the platform rather than naming it explicitly, so
+		// this path works even for hosts outside the Targets matrix.
+		configure = []string{"sh", "./config", "--prefix=" + dist, "no-shared", "no-dso", "no-zlib"}
+	} else {
+		platform := env.Target.OpenSSLPlatform
+		if platform == "" {
+			return fmt.Errorf("no OpenSSL Configure platform known for target %v", env.Target)
+		}
+		configure = []string{"sh", "./Configure", platform, "--prefix=" + dist, "no-shared", "no-dso", "no-zlib"}
+	}
+	return RunCmd(ctx, env, c.Name(), env.Target.CrossEnv(), configure[0], configure[1:]...)
+}
+
+func (c *opensslComponent) Build(ctx context.Context, env *Env) error {
+	return RunCmds(ctx, env, c.Name(), env.Target.CrossEnv(), [][]string{
+		{"make", "depend"},
+		{"make"},
+	})
+}
+
+func (c *opensslComponent) Install(ctx context.Context, env *Env) error {
+	return RunCmd(ctx, env, c.Name(), env.Target.CrossEnv(), "make", "install")
+}
+
+func (c *opensslComponent) Clean(ctx context.Context, env *Env) error {
+	if err := os.RemoveAll(c.Name() + "/dist/lib"); err != nil {
+		return fmt.Errorf("unable to remove openssl/dist/lib: %v", err)
+	}
+	return cleanMakefile(ctx, env, c.Name(), "", nil, nil)
+}
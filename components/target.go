@@ -0,0 +1,167 @@
+package components
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Target describes a single GOOS/GOARCH cross-compilation target and the
+// toolchain needed to build the C dependencies for it.
+type Target struct {
+	GOOS   string
+	GOARCH string
+	// CC, AR, and RANLIB are the cross-toolchain binaries used for the
+	// autoconf-based components (libevent, xz, tor). Empty means "use the
+	// host default", i.e. a native build.
+	CC     string
+	AR     string
+	RANLIB string
+	// Host is the autoconf --host= triple, e.g. "aarch64-linux-gnu".
+	Host string
+	// SysRoot, if set, is passed as --sysroot= / CFLAGS sysroot to the
+	// cross-toolchain.
+	SysRoot string
+	// OpenSSLPlatform is the platform string passed to OpenSSL's
+	// ./Configure, e.g. "linux-aarch64" or "mingw64".
+	OpenSSLPlatform string
+}
+
+// String returns the target in "goos/goarch" form, matching the -target flag
+// syntax and Go's own GOOS/GOARCH naming.
+func (t Target) String() string {
+	return t.GOOS + "/" + t.GOARCH
+}
+
+// Native reports whether this target matches the host Go toolchain, meaning
+// no cross-compilation toolchain overrides are needed.
+func (t Target) Native() bool {
+	return t.GOOS == runtime.GOOS && t.GOARCH == runtime.GOARCH
+}
+
+// Targets is the supported cross-compilation matrix, analogous to the
+// platforms Go's own release tooling builds for.
+var Targets = []Target{
+	{GOOS: "linux", GOARCH: "amd64", OpenSSLPlatform: "linux-x86_64"},
+	{
+		GOOS: "linux", GOARCH: "arm64",
+		CC: "aarch64-linux-gnu-gcc", AR: "aarch64-linux-gnu-ar", RANLIB: "aarch64-linux-gnu-ranlib",
+		Host: "aarch64-linux-gnu", OpenSSLPlatform: "linux-aarch64",
+	},
+	// darwin's toolchain comes from the crazymax/osxcross container image
+	// (see containerBaseImages in exec.go) rather than a host-installed
+	// cross-compiler, so these only produce correct output under
+	// -builder=docker/podman.
+	{
+		GOOS: "darwin", GOARCH: "amd64",
+		CC: "x86_64-apple-darwin20.4-clang", AR: "x86_64-apple-darwin20.4-ar", RANLIB: "x86_64-apple-darwin20.4-ranlib",
+		Host: "x86_64-apple-darwin20.4", OpenSSLPlatform: "darwin64-x86_64-cc",
+	},
+	{
+		GOOS: "darwin", GOARCH: "arm64",
+		CC: "arm64-apple-darwin20.4-clang", AR: "arm64-apple-darwin20.4-ar", RANLIB: "arm64-apple-darwin20.4-ranlib",
+		Host: "aarch64-apple-darwin20.4", OpenSSLPlatform: "darwin64-arm64-cc",
+	},
+	{
+		GOOS: "windows", GOARCH: "amd64",
+		CC: "x86_64-w64-mingw32-gcc", AR: "x86_64-w64-mingw32-ar", RANLIB: "x86_64-w64-mingw32-ranlib",
+		Host: "x86_64-w64-mingw32", OpenSSLPlatform: "mingw64",
+	},
+	// freebsd only has a native toolchain story: there's no cross-compiler
+	// package or pinned container image for it (see containerBaseImages in
+	// exec.go), so CC/AR/RANLIB/Host stay empty. Only building
+	// freebsd/amd64 from a freebsd/amd64 host is actually supported;
+	// -targets-all from another host would hand OpenSSL's ./Configure a
+	// BSD platform string while using the host's own compiler.
+	{GOOS: "freebsd", GOARCH: "amd64", OpenSSLPlatform: "BSD-x86_64"},
+	{
+		GOOS: "android", GOARCH: "arm64",
+		CC: "aarch64-linux-android-clang", AR: "aarch64-linux-android-ar", RANLIB: "aarch64-linux-android-ranlib",
+		Host: "aarch64-linux-android", OpenSSLPlatform: "linux-aarch64",
+	},
+}
+
+// HostTarget is the native (non-cross-compiling) target for the machine
+// running the build, used when neither -target nor -targets-all is given.
+func HostTarget() Target {
+	for _, t := range Targets {
+		if t.Native() {
+			return t
+		}
+	}
+	return Target{GOOS: runtime.GOOS, GOARCH: runtime.GOARCH}
+}
+
+// DistDir is the folder a component's build output should be installed to,
+// rooted at repoDir. Native builds keep the historical "<folder>/dist" path
+// for backwards compatibility; cross-compiled targets get their own subtree
+// so multiple targets can be staged side by side.
+func (t Target) DistDir(repoDir, folder string) string {
+	dir := repoDir + "/" + folder + "/dist"
+	if !t.Native() {
+		dir += "/" + t.GOOS + "_" + t.GOARCH
+	}
+	return dir
+}
+
+// DistDirRel is DistDir without the repoDir prefix: a path relative to the
+// repo root, suitable for a native os.Stat/os.Open from a process already
+// running there. DistDir's repoDir is absCurrDir, which on Windows is
+// rewritten to an MSYS-style "/c/..." path for passing to shell tools;
+// os.Stat doesn't understand that form, so callers that need to check a
+// dist/ file's presence natively should use this instead.
+func (t Target) DistDirRel(folder string) string {
+	dir := folder + "/dist"
+	if !t.Native() {
+		dir += "/" + t.GOOS + "_" + t.GOARCH
+	}
+	return dir
+}
+
+// CrossEnv returns the CC/AR/RANLIB environment overrides for an autoconf
+// build, empty for native targets so the host toolchain is picked up as
+// before.
+func (t Target) CrossEnv() []string {
+	if t.Native() {
+		return nil
+	}
+	var env []string
+	if t.CC != "" {
+		env = append(env, "CC="+t.CC)
+	}
+	if t.AR != "" {
+		env = append(env, "AR="+t.AR)
+	}
+	if t.RANLIB != "" {
+		env = append(env, "RANLIB="+t.RANLIB)
+	}
+	return env
+}
+
+// CrossConfigureArgs returns the --host=/--sysroot= autoconf flags for a
+// cross-compiling target, empty for native targets.
+func (t Target) CrossConfigureArgs() []string {
+	if t.Native() || t.Host == "" {
+		return nil
+	}
+	args := []string{"--host=" + t.Host}
+	if t.SysRoot != "" {
+		args = append(args, "--with-sysroot="+t.SysRoot)
+	}
+	return args
+}
+
+// LookupTarget finds the matrix entry for a "goos/goarch" string as passed
+// to -target.
+func LookupTarget(s string) (Target, error) {
+	goos, goarch, ok := strings.Cut(s, "/")
+	if !ok {
+		return Target{}, fmt.Errorf("invalid -target %q, expected GOOS/GOARCH", s)
+	}
+	for _, t := range Targets {
+		if t.GOOS == goos && t.GOARCH == goarch {
+			return t, nil
+		}
+	}
+	return Target{}, fmt.Errorf("unsupported -target %q, must be one of the supported platforms", s)
+}
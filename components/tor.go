@@ -0,0 +1,53 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// torComponent builds tor itself, statically linked against libevent,
+// openssl, and zlib.
+type torComponent struct{}
+
+func (c *torComponent) Name() string           { return "tor" }
+func (c *torComponent) Dependencies() []string { return []string{"openssl", "libevent", "zlib", "xz"} }
+
+func (c *torComponent) Configure(ctx context.Context, env *Env) error {
+	// We have to make a symlink from zlib to openssl. opensslDist/zlibDist
+	// are built for passing to shell tools (ln), so on Windows they're the
+	// MSYS-style path os.Stat can't resolve; check for the file natively
+	// via the repo-relative path instead.
+	zlibDist := env.Dist("zlib")
+	opensslDist := env.Dist("openssl")
+	if _, err := os.Stat(env.Target.DistDirRel("openssl") + "/lib/libz.a"); os.IsNotExist(err) {
+		if err := RunCmd(ctx, env, "", nil, "ln", "-s", zlibDist+"/lib/libz.a", opensslDist+"/lib/libz.a"); err != nil {
+			return fmt.Errorf("unable to make symlink: %v", err)
+		}
+	}
+	if err := RunCmd(ctx, env, c.Name(), nil, "sh", "-l", "./autogen.sh"); err != nil {
+		return err
+	}
+	cmdEnv := env.Target.CrossEnv()
+	if env.Target.GOOS == "windows" {
+		cmdEnv = append(cmdEnv, "LIBS=-lcrypt32")
+	}
+	args := []string{"./configure", "--prefix=" + env.Dist(c.Name()), "--disable-gcc-hardening", "--enable-static-tor",
+		"--enable-static-libevent", "--with-libevent-dir=" + env.Dist("libevent"), "--enable-static-openssl",
+		"--with-openssl-dir=" + opensslDist, "--enable-static-zlib",
+		"--with-zlib-dir=" + opensslDist, "--disable-system-torrc", "--disable-asciidoc"}
+	args = append(args, env.Target.CrossConfigureArgs()...)
+	return RunCmd(ctx, env, c.Name(), cmdEnv, "sh", args...)
+}
+
+func (c *torComponent) Build(ctx context.Context, env *Env) error {
+	return RunCmd(ctx, env, c.Name(), env.Target.CrossEnv(), "make")
+}
+
+func (c *torComponent) Install(ctx context.Context, env *Env) error {
+	return RunCmd(ctx, env, c.Name(), env.Target.CrossEnv(), "make", "install")
+}
+
+func (c *torComponent) Clean(ctx context.Context, env *Env) error {
+	return cleanMakefile(ctx, env, c.Name(), "", nil, nil)
+}
@@ -0,0 +1,33 @@
+package components
+
+import "context"
+
+// xzComponent builds xz (liblzma) as a static library, skipping the CLI
+// tools and docs tor-static doesn't need.
+type xzComponent struct{}
+
+func (c *xzComponent) Name() string           { return "xz" }
+func (c *xzComponent) Dependencies() []string { return nil }
+
+func (c *xzComponent) Configure(ctx context.Context, env *Env) error {
+	if err := RunCmd(ctx, env, c.Name(), nil, "sh", "-l", "./autogen.sh"); err != nil {
+		return err
+	}
+	args := []string{"./configure", "--prefix=" + env.Dist(c.Name()), "--disable-shared", "--enable-static",
+		"--disable-doc", "--disable-scripts", "--disable-xz", "--disable-xzdec", "--disable-lzmadec",
+		"--disable-lzmainfo", "--disable-lzma-links"}
+	args = append(args, env.Target.CrossConfigureArgs()...)
+	return RunCmd(ctx, env, c.Name(), env.Target.CrossEnv(), "sh", args...)
+}
+
+func (c *xzComponent) Build(ctx context.Context, env *Env) error {
+	return RunCmd(ctx, env, c.Name(), env.Target.CrossEnv(), "make")
+}
+
+func (c *xzComponent) Install(ctx context.Context, env *Env) error {
+	return RunCmd(ctx, env, c.Name(), env.Target.CrossEnv(), "make", "install")
+}
+
+func (c *xzComponent) Clean(ctx context.Context, env *Env) error {
+	return cleanMakefile(ctx, env, c.Name(), "", nil, nil)
+}
@@ -0,0 +1,48 @@
+package components
+
+import "context"
+
+// zlibComponent builds zlib. Windows targets skip autoconf entirely and
+// build straight from the win32 GCC makefile instead.
+type zlibComponent struct{}
+
+func (c *zlibComponent) Name() string           { return "zlib" }
+func (c *zlibComponent) Dependencies() []string { return nil }
+
+func (c *zlibComponent) Configure(ctx context.Context, env *Env) error {
+	if env.Target.GOOS == "windows" {
+		return nil
+	}
+	return RunCmd(ctx, env, c.Name(), env.Target.CrossEnv(), "sh", "./configure", "--prefix="+env.Dist(c.Name()))
+}
+
+func (c *zlibComponent) Build(ctx context.Context, env *Env) error {
+	if env.Target.GOOS == "windows" {
+		args := []string{"-fwin32/Makefile.gcc"}
+		if env.Target.CC != "" {
+			args = append(args, "CC="+env.Target.CC, "AR="+env.Target.AR, "RANLIB="+env.Target.RANLIB)
+		}
+		return RunCmd(ctx, env, c.Name(), c.windowsEnv(env), "make", args...)
+	}
+	return RunCmd(ctx, env, c.Name(), env.Target.CrossEnv(), "make")
+}
+
+func (c *zlibComponent) Install(ctx context.Context, env *Env) error {
+	if env.Target.GOOS == "windows" {
+		return RunCmd(ctx, env, c.Name(), c.windowsEnv(env), "make", "install", "-fwin32/Makefile.gcc")
+	}
+	return RunCmd(ctx, env, c.Name(), env.Target.CrossEnv(), "make", "install")
+}
+
+func (c *zlibComponent) Clean(ctx context.Context, env *Env) error {
+	if env.Target.GOOS == "windows" {
+		return cleanMakefile(ctx, env, c.Name(), "win32/Makefile.gcc", c.windowsEnv(env), []string{"-fwin32/Makefile.gcc"})
+	}
+	return cleanMakefile(ctx, env, c.Name(), "", []string{"PREFIX=" + env.Dist(c.Name())}, nil)
+}
+
+func (c *zlibComponent) windowsEnv(env *Env) []string {
+	dist := env.Dist(c.Name())
+	return append(env.Target.CrossEnv(), "PREFIX="+dist, "BINARY_PATH="+dist+"/bin",
+		"INCLUDE_PATH="+dist+"/include", "LIBRARY_PATH="+dist+"/lib")
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brerodrigues/tor-static/components"
+)
+
+// reproducibleModTime is stamped on every archive entry so two runs of
+// package-all on the same dist/ tree produce byte-identical archives,
+// regardless of when or on which machine they were built.
+var reproducibleModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// manifestFile is one entry of MANIFEST.json, describing a single file
+// staged into the archive.
+type manifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// manifest is the reproducibility record written alongside (and into) every
+// release archive: what went in, which upstream versions, and which commit
+// of this repo built it.
+type manifest struct {
+	GOOS       string            `json:"goos"`
+	GOARCH     string            `json:"goarch"`
+	GitCommit  string            `json:"git_commit"`
+	Components map[string]string `json:"component_versions"`
+	Files      []manifestFile    `json:"files"`
+}
+
+// packageCmd implements package-all and package-<folder>.
+func packageCmd(folder string, target components.Target) error {
+	comps := []string{folder}
+	name := folder
+	if folder == "all" {
+		comps = registry.Names()
+		name = "tor-static"
+	}
+	return packageArchive(name, comps, target)
+}
+
+// packageArchive stages the dist/ output of comps, computes their SHA-256
+// manifest, and writes a single reproducible tar.gz (or zip, on Windows
+// targets) into outputDir.
+func packageArchive(name string, comps []string, target components.Target) error {
+	versions := make(map[string]string, len(comps))
+	for _, comp := range comps {
+		versions[comp] = componentVersion(comp)
+	}
+	commit, err := gitCommit()
+	if err != nil {
+		log.Printf("Warning: unable to determine git commit: %v", err)
+		commit = "unknown"
+	}
+
+	var files []manifestFile
+	type sourced struct {
+		manifestFile
+		abs string
+	}
+	var staged []sourced
+	multi := len(comps) > 1
+	for _, comp := range comps {
+		dist := target.DistDir(absCurrDir, comp)
+		if info, err := os.Stat(dist); err != nil || !info.IsDir() {
+			log.Printf("Warning: %v has no dist/ output, skipping from package", comp)
+			continue
+		}
+		err := filepath.Walk(dist, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			// Walk reports symlinks (e.g. openssl/lib/libz.a, linked to
+			// zlib's output) via Lstat, whose Size is the link text's
+			// length rather than the target's. Stat here to record the
+			// size of what actually gets archived.
+			fi, err := os.Stat(p)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(dist, p)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if multi {
+				rel = comp + "/" + rel
+			}
+			sum, err := sha256File(p)
+			if err != nil {
+				return err
+			}
+			staged = append(staged, sourced{
+				manifestFile: manifestFile{Path: rel, Size: fi.Size(), SHA256: sum},
+				abs:          p,
+			})
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("walking %v dist: %w", comp, err)
+		}
+	}
+	sort.Slice(staged, func(i, j int) bool { return staged[i].Path < staged[j].Path })
+	for _, s := range staged {
+		files = append(files, s.manifestFile)
+	}
+
+	m := manifest{
+		GOOS:       target.GOOS,
+		GOARCH:     target.GOARCH,
+		GitCommit:  commit,
+		Components: versions,
+		Files:      files,
+	}
+	manifestJSON, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling MANIFEST.json: %w", err)
+	}
+
+	// The bundle itself has no version of its own: package-all's "name" is
+	// just "tor-static", so fall back to the tor component's version, the
+	// one that defines what release this tree actually is.
+	version := name
+	if v, ok := versions[name]; ok {
+		version = v
+	} else if len(comps) == 1 {
+		version = versions[comps[0]]
+	} else if v, ok := versions["tor"]; ok {
+		version = v
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("creating %v: %w", outputDir, err)
+	}
+	base := fmt.Sprintf("%v-%v-%v-%v", name, version, target.GOOS, target.GOARCH)
+
+	absFiles := make([]string, len(staged))
+	for i, s := range staged {
+		absFiles[i] = s.abs
+	}
+	relFiles := make([]string, len(staged))
+	for i, s := range staged {
+		relFiles[i] = s.Path
+	}
+
+	var archivePath string
+	if target.GOOS == "windows" {
+		archivePath = filepath.Join(outputDir, base+".zip")
+		err = writeZipArchive(archivePath, relFiles, absFiles, manifestJSON)
+	} else {
+		archivePath = filepath.Join(outputDir, base+".tar.gz")
+		err = writeTarGzArchive(archivePath, relFiles, absFiles, manifestJSON)
+	}
+	if err != nil {
+		return fmt.Errorf("writing %v: %w", archivePath, err)
+	}
+
+	manifestPath := filepath.Join(outputDir, base+".MANIFEST.json")
+	if err := os.WriteFile(manifestPath, manifestJSON, 0644); err != nil {
+		return fmt.Errorf("writing %v: %w", manifestPath, err)
+	}
+	log.Printf("Wrote %v (%v files, manifest %v)", archivePath, len(files), manifestPath)
+	return nil
+}
+
+// archiveMode normalizes a file's permission bits so archives don't carry
+// umask/mtime noise from the building machine: 0755 for anything with an
+// executable bit set, 0644 otherwise.
+func archiveMode(path string) (os.FileMode, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if info.Mode()&0111 != 0 {
+		return 0755, nil
+	}
+	return 0644, nil
+}
+
+func writeTarGzArchive(archivePath string, relPaths, absPaths []string, manifestJSON []byte) error {
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	defer gw.Close()
+	tw := tar.NewWriter(gw)
+	defer tw.Close()
+
+	writeEntry := func(name string, mode os.FileMode, size int64, r io.Reader) error {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    int64(mode),
+			Size:    size,
+			ModTime: reproducibleModTime,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := io.Copy(tw, r)
+		return err
+	}
+
+	for i, rel := range relPaths {
+		mode, err := archiveMode(absPaths[i])
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(absPaths[i])
+		if err != nil {
+			return err
+		}
+		src, err := os.Open(absPaths[i])
+		if err != nil {
+			return err
+		}
+		err = writeEntry(rel, mode, info.Size(), src)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return writeEntry("MANIFEST.json", 0644, int64(len(manifestJSON)), strings.NewReader(string(manifestJSON)))
+}
+
+func writeZipArchive(archivePath string, relPaths, absPaths []string, manifestJSON []byte) error {
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	defer zw.Close()
+
+	writeEntry := func(name string, mode os.FileMode, r io.Reader) error {
+		hdr := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		hdr.SetMode(mode)
+		hdr.Modified = reproducibleModTime
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, r)
+		return err
+	}
+
+	for i, rel := range relPaths {
+		mode, err := archiveMode(absPaths[i])
+		if err != nil {
+			return err
+		}
+		src, err := os.Open(absPaths[i])
+		if err != nil {
+			return err
+		}
+		err = writeEntry(rel, mode, src)
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return writeEntry("MANIFEST.json", 0644, strings.NewReader(string(manifestJSON)))
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func gitCommit() (string, error) {
+	out, err := exec.Command("git", "-C", absCurrDir, "rev-parse", "HEAD").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, out)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// acInitVersion matches the second argument of autoconf's AC_INIT(name,
+// version, ...) macro, as used by libevent, xz, and tor's configure.ac.
+var acInitVersion = regexp.MustCompile(`AC_INIT\(\s*\[?[^,\]]+\]?\s*,\s*\[?([^,\]\s]+)\]?`)
+
+// componentVersion parses the upstream version of folder from whichever
+// version file it ships, falling back to "unknown" if the checkout isn't
+// present or the format isn't recognized.
+func componentVersion(folder string) string {
+	switch folder {
+	case "openssl":
+		if v, err := parseOpenSSLVersion(filepath.Join(folder, "VERSION.dat")); err == nil {
+			return v
+		}
+		if byts, err := os.ReadFile(filepath.Join(folder, "VERSION")); err == nil {
+			return strings.TrimSpace(string(byts))
+		}
+	case "libevent", "xz", "tor":
+		if v, err := parseAutoconfVersion(filepath.Join(folder, "configure.ac")); err == nil {
+			return v
+		}
+	}
+	return "unknown"
+}
+
+func parseAutoconfVersion(path string) (string, error) {
+	byts, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	m := acInitVersion.FindSubmatch(byts)
+	if m == nil {
+		return "", fmt.Errorf("no AC_INIT version found in %v", path)
+	}
+	return string(m[1]), nil
+}
+
+// parseOpenSSLVersion reads OpenSSL's VERSION.dat, a simple KEY=value file
+// with MAJOR/MINOR/PATCH entries.
+func parseOpenSSLVersion(path string) (string, error) {
+	byts, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	fields := map[string]string{}
+	for _, line := range strings.Split(string(byts), "\n") {
+		line = strings.TrimSpace(line)
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		fields[strings.TrimSpace(k)] = strings.Trim(strings.TrimSpace(v), `"`)
+	}
+	major, minor, patch := fields["MAJOR"], fields["MINOR"], fields["PATCH"]
+	if major == "" {
+		return "", fmt.Errorf("no MAJOR field found in %v", path)
+	}
+	return fmt.Sprintf("%v.%v.%v", major, minor, patch), nil
+}